@@ -0,0 +1,518 @@
+// This example demonstrates a live src plugin that watches a minio bucket for
+// object creation notifications and streams the concatenation of newly-arrived
+// objects downstream as they appear. Since minio implements the S3 API this
+// plugin could also be used for S3 buckets by setting the correct endpoints
+// and credentials.
+//
+// By default this plugin will use the credentials set in the environment at MINIO_ACCESS_KEY_ID
+// and MINIO_SECRET_ACCESS_KEY however these can also be set on the element directly.
+//
+//
+// In order to build the plugin for use by GStreamer, you can do the following:
+//
+//     $ go generate
+//     $ go build -o libgstminiolistsrc.so -buildmode c-shared .
+//
+//
+//go:generate gst-plugin-gen
+//
+// +plugin:Name=miniolistsrc
+// +plugin:Description=GStreamer plugins for reading and writing from Minio
+// +plugin:Version=v0.0.1
+// +plugin:License=gst.LicenseLGPL
+// +plugin:Source=go-gst
+// +plugin:Package=examples
+// +plugin:Origin=https://github.com/tinyzimmer/go-gst
+// +plugin:ReleaseDate=2021-01-11
+//
+// +element:Name=miniolistsrc
+// +element:Rank=gst.RankNone
+// +element:Impl=minioListSrc
+// +element:Subclass=base.ExtendsBaseSrc
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/tinyzimmer/go-glib/glib"
+	"github.com/tinyzimmer/go-gst/gst"
+	"github.com/tinyzimmer/go-gst/gst/base"
+)
+
+func main() {}
+
+const (
+	accessKeyIDEnvVar     = "MINIO_ACCESS_KEY_ID"
+	secretAccessKeyEnvVar = "MINIO_SECRET_ACCESS_KEY"
+
+	defaultEvents = "s3:ObjectCreated:*"
+
+	// pendingQueueSize bounds the number of not-yet-consumed objects buffered
+	// between the notification goroutine and Fill.
+	pendingQueueSize = 16
+)
+
+var (
+	defaultEndpoint = "play.min.io"
+	defaultUseTLS   = true
+	defaultRegion   = "us-east-1"
+)
+
+// CAT is the plugin log category
+var CAT = gst.NewDebugCategory(
+	"miniolistsrc",
+	gst.DebugColorNone,
+	"MinIOListSrc Element",
+)
+
+var properties = []*gst.ParamSpec{
+	gst.NewStringParam(
+		"endpoint",
+		"S3 API Endpoint",
+		"The endpoint for the S3 API server",
+		&defaultEndpoint,
+		gst.ParameterReadWrite,
+	),
+	gst.NewBoolParam(
+		"use-tls",
+		"Use TLS",
+		"Use HTTPS for API requests",
+		defaultUseTLS,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"region",
+		"Bucket region",
+		"The region where the bucket is",
+		&defaultRegion,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"bucket",
+		"Bucket name",
+		"The name of the bucket to watch for new objects",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"prefix",
+		"Key prefix filter",
+		"Only stream objects whose key starts with this prefix",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"suffix",
+		"Key suffix filter",
+		"Only stream objects whose key ends with this suffix",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"events",
+		"Notification events",
+		"Comma-separated list of bucket notification events to subscribe to",
+		&defaultEvents,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"access-key-id",
+		"Access Key ID",
+		"The access key ID to use for authentication",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"secret-access-key",
+		"Secret Access Key",
+		"The secret access key to use for authentication",
+		nil,
+		gst.ParameterReadWrite,
+	),
+}
+
+type settings struct {
+	endpoint        string
+	useTLS          bool
+	region          string
+	bucket          string
+	prefix          string
+	suffix          string
+	events          string
+	accessKeyID     string
+	secretAccessKey string
+}
+
+func defaultSettings() *settings {
+	return &settings{
+		endpoint:        defaultEndpoint,
+		useTLS:          defaultUseTLS,
+		region:          defaultRegion,
+		events:          defaultEvents,
+		accessKeyID:     os.Getenv(accessKeyIDEnvVar),
+		secretAccessKey: os.Getenv(secretAccessKeyEnvVar),
+	}
+}
+
+func (s *settings) eventList() []string {
+	var events []string
+	for _, e := range strings.Split(s.events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// state is guarded by mux for every field, except for sends/receives on the
+// chunks and errs channels themselves, which have their own synchronization.
+// Fill does not hold mux for the span of its blocking select (that would
+// block Unlock/Stop for as long as the bucket stays idle), so it takes mux
+// only for the brief reads/writes of started/unlock/pending/gotObject around
+// that select.
+type state struct {
+	started bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	chunks    chan []byte
+	errs      chan error
+	pending   []byte
+	gotObject bool
+
+	// unlock is closed by Unlock to interrupt a Fill blocked waiting on chunks/errs,
+	// and replaced by UnlockStop once the element is ready to resume blocking.
+	unlock chan struct{}
+
+	mux sync.Mutex
+}
+
+type minioListSrc struct {
+	settings *settings
+	state    *state
+}
+
+func (m *minioListSrc) New() gst.GoElement {
+	CAT.Log(gst.LevelLog, "Creating new minioListSrc object")
+	return &minioListSrc{
+		settings: defaultSettings(),
+		state:    &state{unlock: make(chan struct{})},
+	}
+}
+
+func (m *minioListSrc) TypeInit(*gst.TypeInstance) {}
+
+func (m *minioListSrc) ClassInit(klass *gst.ElementClass) {
+	CAT.Log(gst.LevelLog, "Initializing miniolistsrc class")
+	klass.SetMetadata(
+		"MinIO Notification List Source",
+		"Source/Network",
+		"Stream objects as they are created in a MinIO bucket",
+		"Avi Zimmerman <avi.zimmerman@gmail.com>",
+	)
+	CAT.Log(gst.LevelLog, "Adding src pad template and properties to class")
+	klass.AddPadTemplate(gst.NewPadTemplate(
+		"src",
+		gst.PadDirectionSource,
+		gst.PadPresenceAlways,
+		gst.NewAnyCaps(),
+	))
+	klass.InstallProperties(properties)
+}
+
+func (m *minioListSrc) SetProperty(self *gst.Object, id uint, value *glib.Value) {
+	prop := properties[id]
+
+	val, err := value.GoValue()
+	if err != nil {
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+			fmt.Sprintf("Could not coerce %v to go value", value), err.Error())
+	}
+
+	switch prop.Name() {
+	case "endpoint":
+		m.settings.endpoint = val.(string)
+	case "use-tls":
+		m.settings.useTLS = val.(bool)
+	case "region":
+		m.settings.region = val.(string)
+	case "bucket":
+		m.settings.bucket = val.(string)
+	case "prefix":
+		m.settings.prefix = val.(string)
+	case "suffix":
+		m.settings.suffix = val.(string)
+	case "events":
+		m.settings.events = val.(string)
+	case "access-key-id":
+		m.settings.accessKeyID = val.(string)
+	case "secret-access-key":
+		m.settings.secretAccessKey = val.(string)
+	}
+
+}
+
+func (m *minioListSrc) GetProperty(self *gst.Object, id uint) *glib.Value {
+	prop := properties[id]
+
+	var localVal interface{}
+
+	switch prop.Name() {
+	case "endpoint":
+		localVal = m.settings.endpoint
+	case "use-tls":
+		localVal = m.settings.useTLS
+	case "region":
+		localVal = m.settings.region
+	case "bucket":
+		localVal = m.settings.bucket
+	case "prefix":
+		localVal = m.settings.prefix
+	case "suffix":
+		localVal = m.settings.suffix
+	case "events":
+		localVal = m.settings.events
+	case "access-key-id":
+		localVal = m.settings.accessKeyID
+	case "secret-access-key":
+		localVal = m.settings.secretAccessKey
+
+	default:
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+			fmt.Sprintf("Cannot get invalid property %s", prop.Name()), "")
+		return nil
+	}
+
+	val, err := glib.GValue(localVal)
+	if err != nil {
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed,
+			fmt.Sprintf("Could not convert %v to GValue", localVal),
+			err.Error(),
+		)
+	}
+
+	return val
+}
+
+func (m *minioListSrc) Constructed(self *gst.Object) {
+	self.Log(CAT, gst.LevelLog, "Setting format of GstBaseSrc to time and marking element live")
+	srcBase := base.ToGstBaseSrc(self)
+	srcBase.SetFormat(gst.FormatTime)
+	srcBase.SetLive(true)
+}
+
+func (m *minioListSrc) IsSeekable(*base.GstBaseSrc) bool { return false }
+
+func (m *minioListSrc) GetSize(*base.GstBaseSrc) (bool, int64) { return false, 0 }
+
+func (m *minioListSrc) Start(self *base.GstBaseSrc) bool {
+	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
+
+	if m.state.started {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed, "MinIOListSrc is already started", "")
+		return false
+	}
+
+	if m.settings.bucket == "" {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed, "No source bucket defined", "")
+		return false
+	}
+
+	client, err := minio.New(m.settings.endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(m.settings.accessKeyID, m.settings.secretAccessKey, ""),
+		Secure: m.settings.useTLS,
+	})
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed,
+			fmt.Sprintf("Failed to connect to MinIO endpoint %s", m.settings.endpoint), err.Error())
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Subscribing to notifications on bucket %s (prefix=%q suffix=%q events=%q)",
+		m.settings.bucket, m.settings.prefix, m.settings.suffix, m.settings.events))
+	notifyCh := client.ListenBucketNotification(ctx, m.settings.bucket, m.settings.prefix, m.settings.suffix, m.settings.eventList())
+
+	m.state.chunks = make(chan []byte, pendingQueueSize)
+	m.state.errs = make(chan error, 1)
+	m.state.unlock = make(chan struct{})
+	m.state.pending = nil
+	m.state.gotObject = false
+	m.state.cancel = cancel
+	m.state.started = true
+
+	m.state.wg.Add(1)
+	go m.watchNotifications(self, client, ctx, notifyCh)
+
+	self.StartComplete(gst.FlowOK)
+
+	self.Log(CAT, gst.LevelInfo, "MinIOListSrc has started")
+	return true
+}
+
+// watchNotifications drains bucket notifications until ctx is cancelled,
+// fetching and enqueuing the bytes of each newly-created object.
+func (m *minioListSrc) watchNotifications(self *base.GstBaseSrc, client *minio.Client, ctx context.Context, notifyCh <-chan minio.NotificationInfo) {
+	defer m.state.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification, ok := <-notifyCh:
+			if !ok {
+				return
+			}
+			if notification.Err != nil {
+				self.Log(CAT, gst.LevelWarning, fmt.Sprintf("Notification error: %s", notification.Err.Error()))
+				continue
+			}
+			for _, record := range notification.Records {
+				key := record.S3.Object.Key
+				self.Log(CAT, gst.LevelInfo, fmt.Sprintf("New object notification for %s/%s", m.settings.bucket, key))
+
+				obj, err := client.GetObject(ctx, m.settings.bucket, key, minio.GetObjectOptions{})
+				if err != nil {
+					m.reportErr(fmt.Errorf("failed to get object %q: %w", key, err))
+					continue
+				}
+				data, err := io.ReadAll(obj)
+				obj.Close()
+				if err != nil {
+					m.reportErr(fmt.Errorf("failed to read object %q: %w", key, err))
+					continue
+				}
+
+				select {
+				case m.state.chunks <- data:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// reportErr forwards an object fetch error to Fill, which fails with it, without
+// blocking the notification goroutine if an earlier error is still unread.
+func (m *minioListSrc) reportErr(err error) {
+	select {
+	case m.state.errs <- err:
+	default:
+	}
+}
+
+func (m *minioListSrc) Stop(self *base.GstBaseSrc) bool {
+	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
+
+	if !m.state.started {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings, "MinIOListSrc is not started", "")
+		return false
+	}
+
+	m.state.cancel()
+	m.state.wg.Wait()
+
+	m.state.chunks = nil
+	m.state.errs = nil
+	m.state.pending = nil
+	m.state.started = false
+
+	self.Log(CAT, gst.LevelInfo, "MinIOListSrc has stopped")
+	return true
+}
+
+// Unlock interrupts a Fill call that is blocked waiting for the next bucket
+// notification, so that a pending state change (e.g. to PAUSED) is not held
+// up indefinitely by an idle bucket.
+func (m *minioListSrc) Unlock(self *base.GstBaseSrc) bool {
+	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
+	select {
+	case <-m.state.unlock:
+	default:
+		close(m.state.unlock)
+	}
+	return true
+}
+
+// UnlockStop clears the flushing state set up by Unlock so that the next
+// Fill call blocks on chunks/errs again.
+func (m *minioListSrc) UnlockStop(self *base.GstBaseSrc) bool {
+	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
+	m.state.unlock = make(chan struct{})
+	return true
+}
+
+func (m *minioListSrc) Fill(self *base.GstBaseSrc, offset uint64, size uint, buffer *gst.Buffer) gst.FlowReturn {
+	m.state.mux.Lock()
+	started := m.state.started
+	unlock := m.state.unlock
+	chunks := m.state.chunks
+	errs := m.state.errs
+	pendingLen := len(m.state.pending)
+	m.state.mux.Unlock()
+
+	if !started {
+		self.ErrorMessage(gst.DomainCore, gst.CoreErrorFailed, "MinIOListSrc is not started yet", "")
+		return gst.FlowError
+	}
+
+	for pendingLen == 0 {
+		select {
+		case <-unlock:
+			return gst.FlowFlushing
+		case err := <-errs:
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorRead, "Failed to fetch a notified object", err.Error())
+			return gst.FlowError
+		case chunk, ok := <-chunks:
+			if !ok {
+				return gst.FlowEOS
+			}
+			m.state.mux.Lock()
+			if m.state.gotObject {
+				self.Log(CAT, gst.LevelDebug, "Emitting gap between consecutive objects")
+				// CurrentRunningTime accounts for time spent paused, unlike a
+				// wall-clock elapsed time would, so downstream clock/segment
+				// tracking does not drift across a PAUSED/PLAYING cycle.
+				self.SendEvent(gst.NewGapEvent(self.CurrentRunningTime(), 0))
+			}
+			m.state.gotObject = true
+			m.state.pending = chunk
+			pendingLen = len(m.state.pending)
+			m.state.mux.Unlock()
+		}
+	}
+
+	m.state.mux.Lock()
+	data := m.state.pending
+	if uint(len(data)) > size {
+		data = data[:size]
+	}
+	m.state.pending = m.state.pending[len(data):]
+	m.state.mux.Unlock()
+
+	bufmap := buffer.Map(gst.MapWrite)
+	if bufmap == nil {
+		self.ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed, "Failed to map buffer", "")
+		return gst.FlowError
+	}
+	defer buffer.Unmap()
+
+	bufmap.WriteData(data)
+	buffer.SetSize(int64(len(data)))
+
+	return gst.FlowOK
+}
@@ -0,0 +1,586 @@
+// This example demonstrates a sink plugin that writes the pipeline's byte stream into
+// an object in a minio bucket using a multipart upload. Since minio implements the S3
+// API this plugin could also be used for S3 buckets by setting the correct endpoints
+// and credentials.
+//
+// By default this plugin will use the credentials set in the environment at MINIO_ACCESS_KEY_ID
+// and MINIO_SECRET_ACCESS_KEY however these can also be set on the element directly.
+//
+//
+// In order to build the plugin for use by GStreamer, you can do the following:
+//
+//     $ go generate
+//     $ go build -o libgstminiosink.so -buildmode c-shared .
+//
+//
+//go:generate gst-plugin-gen
+//
+// +plugin:Name=miniosink
+// +plugin:Description=GStreamer plugins for reading and writing from Minio
+// +plugin:Version=v0.0.1
+// +plugin:License=gst.LicenseLGPL
+// +plugin:Source=go-gst
+// +plugin:Package=examples
+// +plugin:Origin=https://github.com/tinyzimmer/go-gst
+// +plugin:ReleaseDate=2021-01-11
+//
+// +element:Name=miniosink
+// +element:Rank=gst.RankNone
+// +element:Impl=minioSink
+// +element:Subclass=base.ExtendsBaseSink
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sync"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+
+	"github.com/tinyzimmer/go-glib/glib"
+	"github.com/tinyzimmer/go-gst/gst"
+	"github.com/tinyzimmer/go-gst/gst/base"
+)
+
+func main() {}
+
+const (
+	accessKeyIDEnvVar     = "MINIO_ACCESS_KEY_ID"
+	secretAccessKeyEnvVar = "MINIO_SECRET_ACCESS_KEY"
+
+	// minPartSize is the smallest part size S3 allows for a multipart upload part,
+	// other than the final part.
+	minPartSize = 5 * 1024 * 1024
+
+	// encryptionTypeNone disables server-side encryption options on the request.
+	encryptionTypeNone = "none"
+	// encryptionTypeSSEC requests customer-provided key server-side encryption.
+	encryptionTypeSSEC = "sse-c"
+	// encryptionTypeSSEKMS requests KMS-managed server-side encryption.
+	encryptionTypeSSEKMS = "sse-kms"
+)
+
+var (
+	defaultEndpoint       = "play.min.io"
+	defaultUseTLS         = true
+	defaultRegion         = "us-east-1"
+	defaultPartSize       = uint64(16 * 1024 * 1024)
+	defaultContentType    = ""
+	defaultStorageCls     = ""
+	defaultEncryptionType = encryptionTypeNone
+)
+
+// CAT is the plugin log category
+var CAT = gst.NewDebugCategory(
+	"miniosink",
+	gst.DebugColorNone,
+	"MinIOSink Element",
+)
+
+var properties = []*gst.ParamSpec{
+	gst.NewStringParam(
+		"endpoint",
+		"S3 API Endpoint",
+		"The endpoint for the S3 API server",
+		&defaultEndpoint,
+		gst.ParameterReadWrite,
+	),
+	gst.NewBoolParam(
+		"use-tls",
+		"Use TLS",
+		"Use HTTPS for API requests",
+		defaultUseTLS,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"region",
+		"Bucket region",
+		"The region where the bucket is",
+		&defaultRegion,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"bucket",
+		"Bucket name",
+		"The name of the bucket to write the object to",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"key",
+		"Object key",
+		"The key of the object to write the stream to",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"access-key-id",
+		"Access Key ID",
+		"The access key ID to use for authentication",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"secret-access-key",
+		"Secret Access Key",
+		"The secret access key to use for authentication",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewUint64Param(
+		"part-size",
+		"Part Size",
+		"Size in bytes of each multipart upload part, minimum 5MiB",
+		minPartSize,
+		1024*1024*1024*5,
+		defaultPartSize,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"content-type",
+		"Content Type",
+		"The content type to set on the uploaded object",
+		&defaultContentType,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"storage-class",
+		"Storage Class",
+		"The S3 storage class to request for the uploaded object",
+		&defaultStorageCls,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"metadata",
+		"User Metadata",
+		"A serialized Gst.Structure of user metadata to attach to the uploaded object",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"encryption-type",
+		"Encryption Type",
+		"The server-side encryption to request for the object: none, sse-c, or sse-kms",
+		&defaultEncryptionType,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"encryption-key",
+		"Encryption Key",
+		"A base64 encoded 32-byte customer key to use for SSE-C",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"kms-key-id",
+		"KMS Key ID",
+		"The ID of the KMS key to use for SSE-KMS",
+		nil,
+		gst.ParameterReadWrite,
+	),
+}
+
+type settings struct {
+	endpoint        string
+	useTLS          bool
+	region          string
+	bucket          string
+	key             string
+	accessKeyID     string
+	secretAccessKey string
+	partSize        uint64
+	contentType     string
+	storageClass    string
+	metadata        string
+	encryptionType  string
+	encryptionKey   string
+	kmsKeyID        string
+}
+
+func defaultSettings() *settings {
+	return &settings{
+		endpoint:        defaultEndpoint,
+		useTLS:          defaultUseTLS,
+		region:          defaultRegion,
+		accessKeyID:     os.Getenv(accessKeyIDEnvVar),
+		secretAccessKey: os.Getenv(secretAccessKeyEnvVar),
+		partSize:        defaultPartSize,
+		contentType:     defaultContentType,
+		storageClass:    defaultStorageCls,
+		encryptionType:  defaultEncryptionType,
+	}
+}
+
+// serverSideEncryption builds the encrypt.ServerSide options implied by the
+// configured encryption-type, or nil if encryption was not requested.
+func (s *settings) serverSideEncryption() (encrypt.ServerSide, error) {
+	switch s.encryptionType {
+	case "", encryptionTypeNone:
+		return nil, nil
+	case encryptionTypeSSEC:
+		key, err := base64.StdEncoding.DecodeString(s.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("encryption-key is not valid base64: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption-key must decode to 32 bytes for sse-c, got %d", len(key))
+		}
+		return encrypt.NewSSEC(key)
+	case encryptionTypeSSEKMS:
+		return encrypt.NewSSEKMS(s.kmsKeyID, nil)
+	default:
+		return nil, fmt.Errorf("unknown encryption-type %q", s.encryptionType)
+	}
+}
+
+// parseMetadata turns the serialized metadata structure into a user metadata map,
+// returning an empty map when no metadata was configured.
+func parseMetadata(serialized string) (map[string]string, error) {
+	out := map[string]string{}
+	if serialized == "" {
+		return out, nil
+	}
+	structure, err := gst.StructureFromString(serialized)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range structure.FieldNames() {
+		val, err := structure.GetValue(name)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = fmt.Sprintf("%v", val)
+	}
+	return out, nil
+}
+
+type state struct {
+	started  bool
+	failed   bool
+	client   *minio.Core
+	uploadID string
+	partNum  int
+	parts    []minio.CompletePart
+	pending  bytes.Buffer
+	sse      encrypt.ServerSide
+
+	mux sync.Mutex
+}
+
+type minioSink struct {
+	settings *settings
+	state    *state
+}
+
+func (m *minioSink) New() gst.GoElement {
+	CAT.Log(gst.LevelLog, "Creating new minioSink object")
+	return &minioSink{
+		settings: defaultSettings(),
+		state:    &state{},
+	}
+}
+
+func (m *minioSink) TypeInit(*gst.TypeInstance) {}
+
+func (m *minioSink) ClassInit(klass *gst.ElementClass) {
+	CAT.Log(gst.LevelLog, "Initializing miniosink class")
+	klass.SetMetadata(
+		"MinIO Sink",
+		"Sink/File",
+		"Write stream to a MinIO object via multipart upload",
+		"Avi Zimmerman <avi.zimmerman@gmail.com>",
+	)
+	CAT.Log(gst.LevelLog, "Adding sink pad template and properties to class")
+	klass.AddPadTemplate(gst.NewPadTemplate(
+		"sink",
+		gst.PadDirectionSink,
+		gst.PadPresenceAlways,
+		gst.NewAnyCaps(),
+	))
+	klass.InstallProperties(properties)
+}
+
+func (m *minioSink) SetProperty(self *gst.Object, id uint, value *glib.Value) {
+	prop := properties[id]
+
+	val, err := value.GoValue()
+	if err != nil {
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+			fmt.Sprintf("Could not coerce %v to go value", value), err.Error())
+	}
+
+	switch prop.Name() {
+	case "endpoint":
+		m.settings.endpoint = val.(string)
+	case "use-tls":
+		m.settings.useTLS = val.(bool)
+	case "region":
+		m.settings.region = val.(string)
+	case "bucket":
+		m.settings.bucket = val.(string)
+	case "key":
+		m.settings.key = val.(string)
+	case "access-key-id":
+		m.settings.accessKeyID = val.(string)
+	case "secret-access-key":
+		m.settings.secretAccessKey = val.(string)
+	case "part-size":
+		m.settings.partSize = val.(uint64)
+	case "content-type":
+		m.settings.contentType = val.(string)
+	case "storage-class":
+		m.settings.storageClass = val.(string)
+	case "metadata":
+		m.settings.metadata = val.(string)
+	case "encryption-type":
+		m.settings.encryptionType = val.(string)
+	case "encryption-key":
+		m.settings.encryptionKey = val.(string)
+	case "kms-key-id":
+		m.settings.kmsKeyID = val.(string)
+	}
+
+}
+
+func (m *minioSink) GetProperty(self *gst.Object, id uint) *glib.Value {
+	prop := properties[id]
+
+	var localVal interface{}
+
+	switch prop.Name() {
+	case "endpoint":
+		localVal = m.settings.endpoint
+	case "use-tls":
+		localVal = m.settings.useTLS
+	case "region":
+		localVal = m.settings.region
+	case "bucket":
+		localVal = m.settings.bucket
+	case "key":
+		localVal = m.settings.key
+	case "access-key-id":
+		localVal = m.settings.accessKeyID
+	case "secret-access-key":
+		localVal = m.settings.secretAccessKey
+	case "part-size":
+		localVal = m.settings.partSize
+	case "content-type":
+		localVal = m.settings.contentType
+	case "storage-class":
+		localVal = m.settings.storageClass
+	case "metadata":
+		localVal = m.settings.metadata
+	case "encryption-type":
+		localVal = m.settings.encryptionType
+	case "encryption-key":
+		localVal = m.settings.encryptionKey
+	case "kms-key-id":
+		localVal = m.settings.kmsKeyID
+
+	default:
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
+			fmt.Sprintf("Cannot get invalid property %s", prop.Name()), "")
+		return nil
+	}
+
+	val, err := glib.GValue(localVal)
+	if err != nil {
+		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed,
+			fmt.Sprintf("Could not convert %v to GValue", localVal),
+			err.Error(),
+		)
+	}
+
+	return val
+}
+
+func (m *minioSink) Start(self *base.GstBaseSink) bool {
+	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
+
+	if m.state.started {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed, "MinIOSink is already started", "")
+		return false
+	}
+
+	if m.settings.bucket == "" {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed, "No destination bucket defined", "")
+		return false
+	}
+
+	if m.settings.key == "" {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed, "No object key defined", "")
+		return false
+	}
+
+	if m.settings.partSize < minPartSize {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings,
+			fmt.Sprintf("part-size must be at least %d bytes", minPartSize), "")
+		return false
+	}
+
+	metadata, err := parseMetadata(m.settings.metadata)
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings, "Failed to parse metadata structure", err.Error())
+		return false
+	}
+
+	sse, err := m.settings.serverSideEncryption()
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings, "Invalid encryption settings", err.Error())
+		return false
+	}
+
+	client, err := minio.NewCore(m.settings.endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(m.settings.accessKeyID, m.settings.secretAccessKey, ""),
+		Secure: m.settings.useTLS,
+	})
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed,
+			fmt.Sprintf("Failed to connect to MinIO endpoint %s", m.settings.endpoint), err.Error())
+		return false
+	}
+
+	self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Starting multipart upload to %s/%s on %s", m.settings.bucket, m.settings.key, m.settings.endpoint))
+	uploadID, err := client.NewMultipartUpload(context.Background(), m.settings.bucket, m.settings.key, minio.PutObjectOptions{
+		ContentType:          m.settings.contentType,
+		StorageClass:         m.settings.storageClass,
+		UserMetadata:         metadata,
+		ServerSideEncryption: sse,
+	})
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorOpenWrite,
+			fmt.Sprintf("Failed to start multipart upload for %q in bucket %q", m.settings.key, m.settings.bucket), err.Error())
+		return false
+	}
+
+	m.state.client = client
+	m.state.uploadID = uploadID
+	m.state.partNum = 0
+	m.state.parts = nil
+	m.state.pending.Reset()
+	m.state.sse = sse
+	m.state.failed = false
+	m.state.started = true
+
+	self.Log(CAT, gst.LevelInfo, "MinIOSink has started")
+	return true
+}
+
+// uploadPart flushes data from the pending buffer as a single part. The caller
+// must hold state.mux.
+func (m *minioSink) uploadPart(self *base.GstBaseSink, data []byte) error {
+	m.state.partNum++
+	part, err := m.state.client.PutObjectPart(
+		context.Background(),
+		m.settings.bucket, m.settings.key,
+		m.state.uploadID, m.state.partNum,
+		bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectPartOptions{ServerSideEncryption: m.state.sse},
+	)
+	if err != nil {
+		return err
+	}
+	m.state.parts = append(m.state.parts, minio.CompletePart{
+		PartNumber: part.PartNumber,
+		ETag:       part.ETag,
+	})
+	self.Log(CAT, gst.LevelLog, fmt.Sprintf("Uploaded part %d (%d bytes)", part.PartNumber, len(data)))
+	return nil
+}
+
+func (m *minioSink) Render(self *base.GstBaseSink, buffer *gst.Buffer) gst.FlowReturn {
+	if !m.state.started {
+		self.ErrorMessage(gst.DomainCore, gst.CoreErrorFailed, "MinIOSink is not started yet", "")
+		return gst.FlowError
+	}
+
+	if m.state.failed {
+		self.ErrorMessage(gst.DomainCore, gst.CoreErrorFailed, "MinIOSink already failed a part upload", "")
+		return gst.FlowError
+	}
+
+	bufmap := buffer.Map(gst.MapRead)
+	if bufmap == nil {
+		self.ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed, "Failed to map buffer", "")
+		return gst.FlowError
+	}
+	defer buffer.Unmap()
+
+	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
+
+	m.state.pending.Write(bufmap.Bytes())
+
+	for uint64(m.state.pending.Len()) >= m.settings.partSize {
+		part := make([]byte, m.settings.partSize)
+		if _, err := m.state.pending.Read(part); err != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorWrite, "Failed to read from pending part buffer", err.Error())
+			m.state.failed = true
+			return gst.FlowError
+		}
+		if err := m.uploadPart(self, part); err != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorWrite,
+				fmt.Sprintf("Failed to upload part %d", m.state.partNum), err.Error())
+			m.state.failed = true
+			return gst.FlowError
+		}
+	}
+
+	return gst.FlowOK
+}
+
+func (m *minioSink) Stop(self *base.GstBaseSink) bool {
+	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
+
+	if !m.state.started {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings, "MinIOSink is not started", "")
+		return false
+	}
+
+	if m.state.failed {
+		self.Log(CAT, gst.LevelWarning, "Render reported a failed part upload, aborting multipart upload instead of completing it")
+		m.abort(self)
+		return false
+	}
+
+	if m.state.pending.Len() > 0 {
+		if err := m.uploadPart(self, m.state.pending.Bytes()); err != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorWrite, "Failed to upload final part", err.Error())
+			m.abort(self)
+			return false
+		}
+		m.state.pending.Reset()
+	}
+
+	self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Completing multipart upload %s", m.state.uploadID))
+	if _, err := m.state.client.CompleteMultipartUpload(
+		context.Background(),
+		m.settings.bucket, m.settings.key,
+		m.state.uploadID, m.state.parts,
+		minio.PutObjectOptions{},
+	); err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorClose, "Failed to complete multipart upload", err.Error())
+		m.abort(self)
+		return false
+	}
+
+	m.state.started = false
+	self.Log(CAT, gst.LevelInfo, "MinIOSink has stopped")
+	return true
+}
+
+// abort aborts the in-progress multipart upload. The caller must hold state.mux.
+func (m *minioSink) abort(self *base.GstBaseSink) {
+	self.Log(CAT, gst.LevelWarning, fmt.Sprintf("Aborting multipart upload %s", m.state.uploadID))
+	if err := m.state.client.AbortMultipartUpload(context.Background(), m.settings.bucket, m.settings.key, m.state.uploadID); err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorClose, "Failed to abort multipart upload", err.Error())
+	}
+	m.state.started = false
+}
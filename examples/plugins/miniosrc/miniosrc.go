@@ -31,6 +31,8 @@ package main
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
@@ -38,6 +40,7 @@ import (
 
 	minio "github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 
 	"github.com/tinyzimmer/go-glib/glib"
 	"github.com/tinyzimmer/go-gst/gst"
@@ -49,13 +52,29 @@ func main() {}
 const (
 	accessKeyIDEnvVar     = "MINIO_ACCESS_KEY_ID"
 	secretAccessKeyEnvVar = "MINIO_SECRET_ACCESS_KEY"
+
+	// encryptionTypeNone disables server-side encryption options on the request.
+	encryptionTypeNone = "none"
+	// encryptionTypeSSEC requests customer-provided key server-side encryption.
+	encryptionTypeSSEC = "sse-c"
+	// encryptionTypeSSEKMS requests KMS-managed server-side encryption.
+	encryptionTypeSSEKMS = "sse-kms"
 )
 
 var (
-	defaultEndpoint = "play.min.io"
-	defaultUseTLS   = true
-	defaultRegion   = "us-east-1"
-	defaultSeekable = true
+	defaultEndpoint           = "play.min.io"
+	defaultUseTLS             = true
+	defaultRegion             = "us-east-1"
+	defaultSeekable           = true
+	defaultEncryptionType     = encryptionTypeNone
+	defaultSelectExpression   = ""
+	defaultSelectInputFormat  = "csv"
+	defaultSelectOutputFormat = "csv"
+	defaultSelectCSVDelimiter = ","
+	defaultSelectCSVHasHeader = true
+	defaultSelectJSONType     = "lines"
+	defaultPrefetchSize       = uint64(0)
+	defaultPrefetchCount      = 2
 )
 
 // CAT is the plugin log category
@@ -122,6 +141,94 @@ var properties = []*gst.ParamSpec{
 		defaultSeekable,
 		gst.ParameterReadWrite,
 	),
+	gst.NewStringParam(
+		"encryption-type",
+		"Encryption Type",
+		"The server-side encryption to request for the object: none, sse-c, or sse-kms",
+		&defaultEncryptionType,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"encryption-key",
+		"Encryption Key",
+		"A base64 encoded 32-byte customer key to use for SSE-C",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"kms-key-id",
+		"KMS Key ID",
+		"The ID of the KMS key to use for SSE-KMS",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"select-expression",
+		"S3 Select Expression",
+		"When set, pushes down this SQL expression to S3 Select instead of streaming the raw object",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"select-input-format",
+		"S3 Select Input Format",
+		"The format of the source object for S3 Select: csv, json, or parquet",
+		&defaultSelectInputFormat,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"select-output-format",
+		"S3 Select Output Format",
+		"The format S3 Select should return rows in: csv or json",
+		&defaultSelectOutputFormat,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"select-csv-delimiter",
+		"S3 Select CSV Delimiter",
+		"The field delimiter to use when the select input or output format is csv",
+		&defaultSelectCSVDelimiter,
+		gst.ParameterReadWrite,
+	),
+	gst.NewBoolParam(
+		"select-csv-header",
+		"S3 Select CSV Header",
+		"Whether the source CSV object has a header row to use for column names",
+		defaultSelectCSVHasHeader,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"select-json-type",
+		"S3 Select JSON Type",
+		"The JSON input type for S3 Select: document or lines",
+		&defaultSelectJSONType,
+		gst.ParameterReadWrite,
+	),
+	gst.NewStringParam(
+		"version-id",
+		"Object Version ID",
+		"Pin reads to a specific version of the object, if bucket versioning is enabled",
+		nil,
+		gst.ParameterReadWrite,
+	),
+	gst.NewUint64Param(
+		"prefetch-size",
+		"Prefetch Window Size",
+		"Size in bytes of each range-prefetch window, 0 disables prefetching",
+		0,
+		1024*1024*1024,
+		defaultPrefetchSize,
+		gst.ParameterReadWrite,
+	),
+	gst.NewIntParam(
+		"prefetch-count",
+		"Prefetch Window Count",
+		"Number of range-prefetch windows to keep in flight when prefetching is enabled",
+		1,
+		32,
+		defaultPrefetchCount,
+		gst.ParameterReadWrite,
+	),
 }
 
 type settings struct {
@@ -133,27 +240,272 @@ type settings struct {
 	accessKeyID     string
 	secretAccessKey string
 	seekable        bool
+	encryptionType  string
+	encryptionKey   string
+	kmsKeyID        string
+
+	selectExpression   string
+	selectInputFormat  string
+	selectOutputFormat string
+	selectCSVDelimiter string
+	selectCSVHasHeader bool
+	selectJSONType     string
+
+	versionID     string
+	prefetchSize  uint64
+	prefetchCount int
 }
 
 func defaultSettings() *settings {
 	return &settings{
-		endpoint:        defaultEndpoint,
-		useTLS:          defaultUseTLS,
-		region:          defaultRegion,
-		accessKeyID:     os.Getenv(accessKeyIDEnvVar),
-		secretAccessKey: os.Getenv(secretAccessKeyEnvVar),
-		seekable:        defaultSeekable,
+		endpoint:           defaultEndpoint,
+		useTLS:             defaultUseTLS,
+		region:             defaultRegion,
+		accessKeyID:        os.Getenv(accessKeyIDEnvVar),
+		secretAccessKey:    os.Getenv(secretAccessKeyEnvVar),
+		seekable:           defaultSeekable,
+		encryptionType:     defaultEncryptionType,
+		selectExpression:   defaultSelectExpression,
+		selectInputFormat:  defaultSelectInputFormat,
+		selectOutputFormat: defaultSelectOutputFormat,
+		selectCSVDelimiter: defaultSelectCSVDelimiter,
+		selectCSVHasHeader: defaultSelectCSVHasHeader,
+		selectJSONType:     defaultSelectJSONType,
+		prefetchSize:       defaultPrefetchSize,
+		prefetchCount:      defaultPrefetchCount,
+	}
+}
+
+// selectOptions builds the minio.SelectObjectOptions implied by the select-*
+// properties. Only called when select-expression is non-empty.
+func (s *settings) selectOptions() (minio.SelectObjectOptions, error) {
+	opts := minio.SelectObjectOptions{
+		Expression:     s.selectExpression,
+		ExpressionType: minio.QueryExpressionTypeSQL,
+	}
+
+	switch s.selectInputFormat {
+	case "csv":
+		headerInfo := minio.CSVFileHeaderInfoNone
+		if s.selectCSVHasHeader {
+			headerInfo = minio.CSVFileHeaderInfoUse
+		}
+		opts.InputSerialization = minio.SelectObjectInputSerialization{
+			CSV: &minio.CSVInputOptions{
+				FileHeaderInfo: headerInfo,
+				FieldDelimiter: s.selectCSVDelimiter,
+			},
+		}
+	case "json":
+		jsonType := minio.JSONLinesType
+		if s.selectJSONType == "document" {
+			jsonType = minio.JSONDocumentType
+		}
+		opts.InputSerialization = minio.SelectObjectInputSerialization{
+			JSON: &minio.JSONInputOptions{Type: jsonType},
+		}
+	case "parquet":
+		opts.InputSerialization = minio.SelectObjectInputSerialization{
+			Parquet: &minio.ParquetInputOptions{},
+		}
+	default:
+		return opts, fmt.Errorf("unsupported select-input-format %q", s.selectInputFormat)
+	}
+
+	switch s.selectOutputFormat {
+	case "csv":
+		opts.OutputSerialization = minio.SelectObjectOutputSerialization{
+			CSV: &minio.CSVOutputOptions{FieldDelimiter: s.selectCSVDelimiter},
+		}
+	case "json":
+		opts.OutputSerialization = minio.SelectObjectOutputSerialization{
+			JSON: &minio.JSONOutputOptions{},
+		}
+	default:
+		return opts, fmt.Errorf("unsupported select-output-format %q", s.selectOutputFormat)
+	}
+
+	return opts, nil
+}
+
+// serverSideEncryption builds the encrypt.ServerSide options implied by the
+// configured encryption-type, or nil if encryption was not requested.
+func (s *settings) serverSideEncryption() (encrypt.ServerSide, error) {
+	switch s.encryptionType {
+	case "", encryptionTypeNone:
+		return nil, nil
+	case encryptionTypeSSEC:
+		key, err := base64.StdEncoding.DecodeString(s.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("encryption-key is not valid base64: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption-key must decode to 32 bytes for sse-c, got %d", len(key))
+		}
+		return encrypt.NewSSEC(key)
+	case encryptionTypeSSEKMS:
+		return encrypt.NewSSEKMS(s.kmsKeyID, nil)
+	default:
+		return nil, fmt.Errorf("unknown encryption-type %q", s.encryptionType)
+	}
+}
+
+// sseMD5 returns the MD5 digest of the decoded SSE-C key, used to annotate log
+// messages without leaking the key itself.
+func sseMD5(encryptionKey string) string {
+	key, err := base64.StdEncoding.DecodeString(encryptionKey)
+	if err != nil {
+		return ""
 	}
+	sum := md5.Sum(key)
+	return base64.StdEncoding.EncodeToString(sum[:])
 }
 
 type state struct {
-	started bool
-	object  *minio.Object
-	objInfo minio.ObjectInfo
+	started    bool
+	client     *minio.Client
+	object     *minio.Object
+	objInfo    minio.ObjectInfo
+	selectMode bool
+	selectRes  *minio.SelectResults
+	prefetch   *prefetchCache
+
+	// cancel tears down the context backing in-flight prefetch fetches. It is
+	// guarded by cancelMu rather than mux, since Fill holds mux for the
+	// duration of a blocking prefetch wait and Stop must be able to cancel
+	// that wait without first acquiring the same lock.
+	cancel   context.CancelFunc
+	cancelMu sync.Mutex
 
 	mux sync.Mutex
 }
 
+// prefetchWindow holds the bytes of a single range-prefetch window, fetched
+// asynchronously. ready is closed once data/err are safe to read.
+type prefetchWindow struct {
+	offset int64
+	ready  chan struct{}
+	data   []byte
+	err    error
+}
+
+// prefetchCache keeps up to settings.prefetchCount windows of settings.prefetchSize
+// bytes each in flight/cached, keyed by their aligned start offset. It is reset
+// when Fill requests an uncached window outside the currently cached span (see
+// ensure).
+type prefetchCache struct {
+	src    *minioSrc
+	client *minio.Client
+	// ctx is cancelled from Stop, so fetches in flight for a stopped element
+	// are aborted instead of running to completion in the background.
+	ctx context.Context
+
+	// windowSize and windowCount are snapshotted from settings.prefetchSize and
+	// settings.prefetchCount when the cache is created, since those properties
+	// can be changed live on a running element and must not change the meaning
+	// of windows already cached or in flight.
+	windowSize  int64
+	windowCount int
+
+	mux             sync.Mutex
+	windows         map[int64]*prefetchWindow
+	order           []int64
+	lastWindowStart int64
+}
+
+func newPrefetchCache(ctx context.Context, src *minioSrc, client *minio.Client) *prefetchCache {
+	return &prefetchCache{
+		src:             src,
+		client:          client,
+		ctx:             ctx,
+		windowSize:      int64(src.settings.prefetchSize),
+		windowCount:     src.settings.prefetchCount,
+		windows:         make(map[int64]*prefetchWindow),
+		lastWindowStart: -1,
+	}
+}
+
+func (p *prefetchCache) windowStart(offset int64) int64 {
+	return (offset / p.windowSize) * p.windowSize
+}
+
+// ensure returns the window covering offset, starting an async fetch for it
+// (and readahead windows after it) if not already cached. A jump to a window
+// outside the currently cached span, and not itself already cached, is
+// treated as a seek and evicts the cache before restarting prefetching
+// around the new offset.
+func (p *prefetchCache) ensure(offset int64) *prefetchWindow {
+	start := p.windowStart(offset)
+	span := int64(p.windowCount) * p.windowSize
+
+	p.mux.Lock()
+	if _, cached := p.windows[start]; !cached && p.lastWindowStart >= 0 &&
+		(start < p.lastWindowStart || start-p.lastWindowStart >= span) {
+		p.windows = make(map[int64]*prefetchWindow)
+		p.order = nil
+	}
+
+	w, ok := p.windows[start]
+	if !ok {
+		w = &prefetchWindow{offset: start, ready: make(chan struct{})}
+		p.windows[start] = w
+		p.order = append(p.order, start)
+		p.evictLocked()
+		go p.fetch(w)
+	}
+	p.lastWindowStart = start
+	p.mux.Unlock()
+
+	for i := int64(1); i < int64(p.windowCount); i++ {
+		p.ensureAhead(start + i*p.windowSize)
+	}
+
+	return w
+}
+
+// ensureAhead kicks off a readahead fetch without waiting on it.
+func (p *prefetchCache) ensureAhead(start int64) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if _, ok := p.windows[start]; ok {
+		return
+	}
+	w := &prefetchWindow{offset: start, ready: make(chan struct{})}
+	p.windows[start] = w
+	p.order = append(p.order, start)
+	p.evictLocked()
+	go p.fetch(w)
+}
+
+// evictLocked drops the oldest window once more than windowCount are cached.
+// The caller must hold p.mux.
+func (p *prefetchCache) evictLocked() {
+	for len(p.order) > p.windowCount {
+		oldest := p.order[0]
+		p.order = p.order[1:]
+		delete(p.windows, oldest)
+	}
+}
+
+func (p *prefetchCache) fetch(w *prefetchWindow) {
+	defer close(w.ready)
+
+	opts := p.src.getObjectOptions()
+	if err := opts.SetRange(w.offset, w.offset+p.windowSize-1); err != nil {
+		w.err = err
+		return
+	}
+
+	obj, err := p.client.GetObject(p.ctx, p.src.settings.bucket, p.src.settings.key, opts)
+	if err != nil {
+		w.err = err
+		return
+	}
+	defer obj.Close()
+
+	w.data, w.err = io.ReadAll(obj)
+}
+
 type minioSrc struct {
 	settings *settings
 	state    *state
@@ -213,6 +565,30 @@ func (m *minioSrc) SetProperty(self *gst.Object, id uint, value *glib.Value) {
 		m.settings.secretAccessKey = val.(string)
 	case "seekable":
 		m.settings.seekable = val.(bool)
+	case "encryption-type":
+		m.settings.encryptionType = val.(string)
+	case "encryption-key":
+		m.settings.encryptionKey = val.(string)
+	case "kms-key-id":
+		m.settings.kmsKeyID = val.(string)
+	case "select-expression":
+		m.settings.selectExpression = val.(string)
+	case "select-input-format":
+		m.settings.selectInputFormat = val.(string)
+	case "select-output-format":
+		m.settings.selectOutputFormat = val.(string)
+	case "select-csv-delimiter":
+		m.settings.selectCSVDelimiter = val.(string)
+	case "select-csv-header":
+		m.settings.selectCSVHasHeader = val.(bool)
+	case "select-json-type":
+		m.settings.selectJSONType = val.(string)
+	case "version-id":
+		m.settings.versionID = val.(string)
+	case "prefetch-size":
+		m.settings.prefetchSize = val.(uint64)
+	case "prefetch-count":
+		m.settings.prefetchCount = val.(int)
 	}
 
 }
@@ -239,6 +615,30 @@ func (m *minioSrc) GetProperty(self *gst.Object, id uint) *glib.Value {
 		localVal = m.settings.secretAccessKey
 	case "seekable":
 		localVal = m.settings.seekable
+	case "encryption-type":
+		localVal = m.settings.encryptionType
+	case "encryption-key":
+		localVal = m.settings.encryptionKey
+	case "kms-key-id":
+		localVal = m.settings.kmsKeyID
+	case "select-expression":
+		localVal = m.settings.selectExpression
+	case "select-input-format":
+		localVal = m.settings.selectInputFormat
+	case "select-output-format":
+		localVal = m.settings.selectOutputFormat
+	case "select-csv-delimiter":
+		localVal = m.settings.selectCSVDelimiter
+	case "select-csv-header":
+		localVal = m.settings.selectCSVHasHeader
+	case "select-json-type":
+		localVal = m.settings.selectJSONType
+	case "version-id":
+		localVal = m.settings.versionID
+	case "prefetch-size":
+		localVal = m.settings.prefetchSize
+	case "prefetch-count":
+		localVal = m.settings.prefetchCount
 
 	default:
 		gst.ToElement(self).ErrorMessage(gst.DomainLibrary, gst.LibraryErrorSettings,
@@ -262,10 +662,57 @@ func (m *minioSrc) Constructed(self *gst.Object) {
 	base.ToGstBaseSrc(self).SetFormat(gst.FormatBytes)
 }
 
-func (m *minioSrc) IsSeekable(*base.GstBaseSrc) bool { return m.settings.seekable }
+// selectStart opens a server-side S3 Select query in place of a plain GetObject
+// and points state.selectRes at the resulting one-shot stream. The caller must
+// hold state.mux.
+func (m *minioSrc) selectStart(self *base.GstBaseSrc, client *minio.Client, sse encrypt.ServerSide) bool {
+	opts, err := m.settings.selectOptions()
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings, "Invalid S3 Select settings", err.Error())
+		return false
+	}
+	if sse != nil {
+		opts.ServerSideEncryption = sse
+	}
+
+	self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Running S3 Select query against %s/%s", m.settings.bucket, m.settings.key))
+	res, err := client.SelectObjectContent(context.Background(), m.settings.bucket, m.settings.key, opts)
+	if err != nil {
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorOpenRead,
+			fmt.Sprintf("Failed to run S3 Select query against %q in bucket %q", m.settings.key, m.settings.bucket), err.Error())
+		return false
+	}
+
+	m.state.selectRes = res
+	m.state.selectMode = true
+
+	self.SetFormat(gst.FormatTime)
+	return true
+}
+
+// getObjectOptions builds the minio.GetObjectOptions shared by the plain read
+// path and range-prefetch fetches: encryption and the pinned object version,
+// if any.
+func (m *minioSrc) getObjectOptions() minio.GetObjectOptions {
+	opts := minio.GetObjectOptions{}
+	if sse, err := m.settings.serverSideEncryption(); err == nil {
+		opts.ServerSideEncryption = sse
+	}
+	if m.settings.versionID != "" {
+		opts.VersionID = m.settings.versionID
+	}
+	return opts
+}
+
+func (m *minioSrc) IsSeekable(*base.GstBaseSrc) bool {
+	if m.state.selectMode {
+		return false
+	}
+	return m.settings.seekable
+}
 
 func (m *minioSrc) GetSize(self *base.GstBaseSrc) (bool, int64) {
-	if !m.state.started {
+	if !m.state.started || m.state.selectMode {
 		return false, 0
 	}
 	return true, m.state.objInfo.Size
@@ -273,6 +720,7 @@ func (m *minioSrc) GetSize(self *base.GstBaseSrc) (bool, int64) {
 
 func (m *minioSrc) Start(self *base.GstBaseSrc) bool {
 	m.state.mux.Lock()
+	defer m.state.mux.Unlock()
 
 	if m.state.started {
 		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorFailed, "MinIOSrc is already started", "")
@@ -300,27 +748,55 @@ func (m *minioSrc) Start(self *base.GstBaseSrc) bool {
 		return false
 	}
 
-	self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Requesting %s/%s from %s", m.settings.bucket, m.settings.key, m.settings.endpoint))
-	m.state.object, err = client.GetObject(context.Background(), m.settings.bucket, m.settings.key, minio.GetObjectOptions{})
+	sse, err := m.settings.serverSideEncryption()
 	if err != nil {
-		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorOpenRead,
-			fmt.Sprintf("Failed to retrieve object %q from bucket %q", m.settings.key, m.settings.bucket), err.Error())
+		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorSettings, "Invalid encryption settings", err.Error())
 		return false
 	}
+	if sse != nil && m.settings.encryptionType == encryptionTypeSSEC {
+		self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Requesting object with SSE-C key (md5 %s)", sseMD5(m.settings.encryptionKey)))
+	}
 
-	self.Log(CAT, gst.LevelInfo, "Getting HEAD for object")
-	m.state.objInfo, err = m.state.object.Stat()
-	if err != nil {
-		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorOpenRead,
-			fmt.Sprintf("Failed to stat object %q in bucket %q: %s", m.settings.key, m.settings.bucket, err.Error()), "")
-		return false
+	m.state.client = client
+
+	if m.settings.selectExpression != "" {
+		if !m.selectStart(self, client, sse) {
+			return false
+		}
+	} else {
+		getOpts := m.getObjectOptions()
+
+		self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Requesting %s/%s from %s", m.settings.bucket, m.settings.key, m.settings.endpoint))
+		m.state.object, err = client.GetObject(context.Background(), m.settings.bucket, m.settings.key, getOpts)
+		if err != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorOpenRead,
+				fmt.Sprintf("Failed to retrieve object %q from bucket %q", m.settings.key, m.settings.bucket), err.Error())
+			return false
+		}
+
+		self.Log(CAT, gst.LevelInfo, "Getting HEAD for object")
+		m.state.objInfo, err = m.state.object.Stat()
+		if err != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorOpenRead,
+				fmt.Sprintf("Failed to stat object %q in bucket %q: %s", m.settings.key, m.settings.bucket, err.Error()), "")
+			return false
+		}
+		self.Log(CAT, gst.LevelInfo, fmt.Sprintf("%+v", m.state.objInfo))
+
+		if m.settings.prefetchSize > 0 {
+			self.Log(CAT, gst.LevelInfo, fmt.Sprintf("Enabling range-prefetch with window size %d and count %d",
+				m.settings.prefetchSize, m.settings.prefetchCount))
+			ctx, cancel := context.WithCancel(context.Background())
+			m.state.cancelMu.Lock()
+			m.state.cancel = cancel
+			m.state.cancelMu.Unlock()
+			m.state.prefetch = newPrefetchCache(ctx, m, client)
+			m.state.prefetch.ensure(0)
+		}
 	}
-	self.Log(CAT, gst.LevelInfo, fmt.Sprintf("%+v", m.state.objInfo))
 
 	m.state.started = true
 
-	m.state.mux.Unlock()
-
 	self.StartComplete(gst.FlowOK)
 
 	self.Log(CAT, gst.LevelInfo, "MinIOSrc has started")
@@ -328,6 +804,15 @@ func (m *minioSrc) Start(self *base.GstBaseSrc) bool {
 }
 
 func (m *minioSrc) Stop(self *base.GstBaseSrc) bool {
+	// Cancel any in-flight prefetch fetches before taking mux: Fill holds mux
+	// for the duration of a blocking prefetch wait, so cancelling first is
+	// what lets that wait (and Fill, and this Lock) return at all.
+	m.state.cancelMu.Lock()
+	if m.state.cancel != nil {
+		m.state.cancel()
+	}
+	m.state.cancelMu.Unlock()
+
 	m.state.mux.Lock()
 	defer m.state.mux.Unlock()
 
@@ -336,21 +821,80 @@ func (m *minioSrc) Stop(self *base.GstBaseSrc) bool {
 		return false
 	}
 
-	if err := m.state.object.Close(); err != nil {
-		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorClose, "Failed to close the bucket object", err.Error())
-		return false
+	if m.state.selectMode {
+		// Reset unconditionally, same as the object branch below: a failed
+		// Close still leaves the select stream unusable, and the element must
+		// not get stuck reporting itself as started.
+		closeErr := m.state.selectRes.Close()
+		m.state.selectRes = nil
+		m.state.selectMode = false
+		if closeErr != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorClose, "Failed to close the select stream", closeErr.Error())
+			m.state.client = nil
+			m.state.started = false
+			return false
+		}
+	} else {
+		// The prefetch context was already cancelled above, so its fetches are
+		// dead regardless of whether Close succeeds; reset it unconditionally
+		// rather than leaving a started element pinned to a cancelled context.
+		closeErr := m.state.object.Close()
+		m.state.object = nil
+		m.state.cancelMu.Lock()
+		m.state.cancel = nil
+		m.state.cancelMu.Unlock()
+		m.state.prefetch = nil
+		if closeErr != nil {
+			self.ErrorMessage(gst.DomainResource, gst.ResourceErrorClose, "Failed to close the bucket object", closeErr.Error())
+			m.state.client = nil
+			m.state.started = false
+			return false
+		}
 	}
 
-	m.state.object = nil
+	m.state.client = nil
 	m.state.started = false
 
 	self.Log(CAT, gst.LevelInfo, "MinIOSrc has stopped")
 	return true
 }
 
+// fillFromPrefetch satisfies a Fill request from the range-prefetch window
+// cache, blocking until the covering window has been fetched.
+func (m *minioSrc) fillFromPrefetch(offset uint64, size uint) ([]byte, error) {
+	cache := m.state.prefetch
+	reqOffset := int64(offset)
+
+	if reqOffset >= m.state.objInfo.Size {
+		return nil, io.EOF
+	}
+
+	w := cache.ensure(reqOffset)
+	<-w.ready
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	rel := reqOffset - w.offset
+	if rel < 0 || rel > int64(len(w.data)) {
+		return nil, fmt.Errorf("prefetch window starting at %d does not cover offset %d", w.offset, reqOffset)
+	}
+
+	if rel == int64(len(w.data)) && int64(len(w.data)) < cache.windowSize {
+		// a short read on this window means the object ended at its tail
+		return nil, io.EOF
+	}
+
+	end := rel + int64(size)
+	if end > int64(len(w.data)) {
+		end = int64(len(w.data))
+	}
+	return w.data[rel:end], nil
+}
+
 func (m *minioSrc) Fill(self *base.GstBaseSrc, offset uint64, size uint, buffer *gst.Buffer) gst.FlowReturn {
 
-	if !m.state.started || m.state.object == nil {
+	if !m.state.started {
 		self.ErrorMessage(gst.DomainCore, gst.CoreErrorFailed, "MinIOSrc is not started yet", "")
 		return gst.FlowError
 	}
@@ -361,7 +905,22 @@ func (m *minioSrc) Fill(self *base.GstBaseSrc, offset uint64, size uint, buffer
 	defer m.state.mux.Unlock()
 
 	data := make([]byte, size)
-	read, err := m.state.object.ReadAt(data, int64(offset))
+	var read int
+	var err error
+	switch {
+	case m.state.selectMode:
+		// the select stream is a one-shot io.Reader, so reads must stay sequential
+		// regardless of the offset GstBaseSrc reports
+		read, err = io.ReadFull(m.state.selectRes, data)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+	case m.state.prefetch != nil:
+		data, err = m.fillFromPrefetch(offset, size)
+		read = len(data)
+	default:
+		read, err = m.state.object.ReadAt(data, int64(offset))
+	}
 	if err != nil && err != io.EOF {
 		self.ErrorMessage(gst.DomainResource, gst.ResourceErrorRead,
 			fmt.Sprintf("Failed to read %d bytes from object at offset %d", size, offset), err.Error())
@@ -375,6 +934,10 @@ func (m *minioSrc) Fill(self *base.GstBaseSrc, offset uint64, size uint, buffer
 		data = trim
 	}
 
+	if read == 0 && err == io.EOF {
+		return gst.FlowEOS
+	}
+
 	bufmap := buffer.Map(gst.MapWrite)
 	if bufmap == nil {
 		self.ErrorMessage(gst.DomainLibrary, gst.LibraryErrorFailed, "Failed to map buffer", "")